@@ -0,0 +1,156 @@
+// Package aggregator stitches multi-line subprocess output (stack traces,
+// tracebacks, panics) into single log records before they are submitted to
+// a logging.Pipeline, using multiline rules modeled after common log-shipper
+// conventions (Filebeat, Fluentd).
+package aggregator
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults applied when the corresponding environment variable isn't set.
+const (
+	DefaultMaxLines = 500
+	DefaultMaxBytes = 1 << 20 // 1MiB
+	DefaultTimeout  = 5 * time.Second
+)
+
+// MatchMode controls which side of a continuation line Pattern describes:
+// MatchAfter lines are appended to the record they follow, MatchBefore
+// lines are prepended to the record they precede.
+type MatchMode string
+
+const (
+	MatchAfter  MatchMode = "after"
+	MatchBefore MatchMode = "before"
+)
+
+// Config describes how to stitch lines of subprocess output into records.
+type Config struct {
+	Pattern  *regexp.Regexp
+	Negate   bool
+	Match    MatchMode
+	Timeout  time.Duration
+	MaxLines int
+	MaxBytes int
+}
+
+// ConfigFromEnv builds a Config from HABERDASHER_MULTILINE_PATTERN,
+// HABERDASHER_MULTILINE_NEGATE, HABERDASHER_MULTILINE_MATCH and
+// HABERDASHER_MULTILINE_TIMEOUT. enabled is false when
+// HABERDASHER_MULTILINE_PATTERN is unset or empty, meaning each line should
+// be treated as its own record.
+func ConfigFromEnv() (cfg Config, enabled bool, err error) {
+	patternStr, exists := os.LookupEnv("HABERDASHER_MULTILINE_PATTERN")
+	if !exists || patternStr == "" {
+		return Config{}, false, nil
+	}
+	pattern, err := regexp.Compile(patternStr)
+	if err != nil {
+		return Config{}, false, err
+	}
+	cfg = Config{
+		Pattern:  pattern,
+		Match:    MatchAfter,
+		Timeout:  DefaultTimeout,
+		MaxLines: DefaultMaxLines,
+		MaxBytes: DefaultMaxBytes,
+	}
+	if negateStr, exists := os.LookupEnv("HABERDASHER_MULTILINE_NEGATE"); exists {
+		cfg.Negate, _ = strconv.ParseBool(negateStr)
+	}
+	if matchStr, exists := os.LookupEnv("HABERDASHER_MULTILINE_MATCH"); exists && matchStr == string(MatchBefore) {
+		cfg.Match = MatchBefore
+	}
+	if timeoutStr, exists := os.LookupEnv("HABERDASHER_MULTILINE_TIMEOUT"); exists {
+		if d, err := time.ParseDuration(timeoutStr); err == nil {
+			cfg.Timeout = d
+		}
+	}
+	return cfg, true, nil
+}
+
+// Record is a single stitched log record, joined from one or more input
+// lines.
+type Record struct {
+	Text    string
+	IsTrace bool
+}
+
+// Aggregator stitches lines fed to it via Feed into Records, buffering
+// continuation lines according to its Config and enforcing MaxLines/
+// MaxBytes so a pattern mismatch can't buffer unbounded input.
+type Aggregator struct {
+	cfg     Config
+	buf     []string
+	bufSize int
+}
+
+// New returns an Aggregator configured by cfg.
+func New(cfg Config) *Aggregator {
+	return &Aggregator{cfg: cfg}
+}
+
+// Feed processes a single input line. It returns a completed Record and
+// ok=true when this line completes a record: in MatchAfter mode (and when
+// MaxLines/MaxBytes is reached) the record buffered before this line, in
+// MatchBefore mode the buffered lines followed by this one. Otherwise the
+// line has been buffered awaiting more continuation lines.
+func (a *Aggregator) Feed(line string) (Record, bool) {
+	isMatch := a.cfg.Pattern.MatchString(line)
+	if a.cfg.Negate {
+		isMatch = !isMatch
+	}
+
+	// Enforce the cap before buffering further, so an unterminated
+	// continuation run can't grow the buffer without limit; this forces a
+	// flush boundary even though the pattern hasn't told us to.
+	if len(a.buf) >= a.cfg.MaxLines || a.bufSize >= a.cfg.MaxBytes {
+		rec, ok := a.flush()
+		a.append(line)
+		return rec, ok
+	}
+
+	switch a.cfg.Match {
+	case MatchBefore:
+		a.append(line)
+		if isMatch {
+			// This line belongs with whatever follows it; keep buffering.
+			return Record{}, false
+		}
+		return a.flush()
+	default: // MatchAfter
+		if isMatch && len(a.buf) > 0 {
+			a.append(line)
+			return Record{}, false
+		}
+		rec, ok := a.flush()
+		a.append(line)
+		return rec, ok
+	}
+}
+
+// Flush forces out whatever is currently buffered, e.g. when the flush
+// timeout elapses or the input stream has ended.
+func (a *Aggregator) Flush() (Record, bool) {
+	return a.flush()
+}
+
+func (a *Aggregator) append(line string) {
+	a.buf = append(a.buf, line)
+	a.bufSize += len(line)
+}
+
+func (a *Aggregator) flush() (Record, bool) {
+	if len(a.buf) == 0 {
+		return Record{}, false
+	}
+	rec := Record{Text: strings.Join(a.buf, "\n"), IsTrace: len(a.buf) > 1}
+	a.buf = nil
+	a.bufSize = 0
+	return rec, true
+}
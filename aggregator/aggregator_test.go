@@ -0,0 +1,113 @@
+package aggregator
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestAggregatorMatchAfter(t *testing.T) {
+	// Continuation lines are indented; a line that isn't indented starts a
+	// new record.
+	cfg := Config{Pattern: regexp.MustCompile(`^\s`), Match: MatchAfter, MaxLines: DefaultMaxLines, MaxBytes: DefaultMaxBytes}
+	a := New(cfg)
+
+	if _, ok := a.Feed("ERROR something broke"); ok {
+		t.Fatal("first line unexpectedly flushed a record")
+	}
+	if _, ok := a.Feed("    at foo.bar()"); ok {
+		t.Fatal("continuation line unexpectedly flushed a record")
+	}
+	if _, ok := a.Feed("    at foo.baz()"); ok {
+		t.Fatal("continuation line unexpectedly flushed a record")
+	}
+
+	rec, ok := a.Feed("INFO next message")
+	if !ok {
+		t.Fatal("non-continuation line should have flushed the buffered record")
+	}
+	want := "ERROR something broke\n    at foo.bar()\n    at foo.baz()"
+	if rec.Text != want {
+		t.Errorf("Text = %q, want %q", rec.Text, want)
+	}
+	if !rec.IsTrace {
+		t.Error("IsTrace = false, want true for a multi-line record")
+	}
+
+	rec, ok = a.Flush()
+	if !ok {
+		t.Fatal("Flush should return the still-buffered last line")
+	}
+	if rec.Text != "INFO next message" {
+		t.Errorf("Text = %q, want %q", rec.Text, "INFO next message")
+	}
+	if rec.IsTrace {
+		t.Error("IsTrace = true, want false for a single-line record")
+	}
+}
+
+func TestAggregatorMatchBefore(t *testing.T) {
+	// A line ending in a backslash continues onto the next line.
+	cfg := Config{Pattern: regexp.MustCompile(`\\$`), Match: MatchBefore, MaxLines: DefaultMaxLines, MaxBytes: DefaultMaxBytes}
+	a := New(cfg)
+
+	if _, ok := a.Feed(`first \`); ok {
+		t.Fatal("continuation line unexpectedly flushed a record")
+	}
+	rec, ok := a.Feed("second")
+	if !ok {
+		t.Fatal("non-continuation line should have flushed the buffered record")
+	}
+	want := "first \\\nsecond"
+	if rec.Text != want {
+		t.Errorf("Text = %q, want %q", rec.Text, want)
+	}
+}
+
+func TestAggregatorMaxLinesCap(t *testing.T) {
+	cfg := Config{Pattern: regexp.MustCompile(`^\s`), Match: MatchAfter, MaxLines: 2, MaxBytes: DefaultMaxBytes}
+	a := New(cfg)
+
+	if _, ok := a.Feed("ERROR something broke"); ok {
+		t.Fatal("first line unexpectedly flushed a record")
+	}
+	if _, ok := a.Feed("    at foo.bar()"); ok {
+		t.Fatal("second line unexpectedly flushed a record")
+	}
+
+	// The buffer is now at MaxLines (2); the next continuation line must
+	// force a flush rather than growing the buffer further.
+	rec, ok := a.Feed("    at foo.baz()")
+	if !ok {
+		t.Fatal("expected a forced flush once MaxLines was reached")
+	}
+	want := "ERROR something broke\n    at foo.bar()"
+	if rec.Text != want {
+		t.Errorf("Text = %q, want %q", rec.Text, want)
+	}
+}
+
+func TestAggregatorMaxBytesCap(t *testing.T) {
+	cfg := Config{Pattern: regexp.MustCompile(`^\s`), Match: MatchAfter, MaxLines: DefaultMaxLines, MaxBytes: 10}
+	a := New(cfg)
+
+	if _, ok := a.Feed("0123456789"); ok {
+		t.Fatal("first line unexpectedly flushed a record")
+	}
+
+	// bufSize is already at MaxBytes (10), so even a continuation line
+	// must force a flush rather than growing the buffer further.
+	rec, ok := a.Feed("    continuation")
+	if !ok {
+		t.Fatal("expected a forced flush once MaxBytes was reached")
+	}
+	if rec.Text != "0123456789" {
+		t.Errorf("Text = %q, want %q", rec.Text, "0123456789")
+	}
+}
+
+func TestAggregatorFlushEmpty(t *testing.T) {
+	a := New(Config{Pattern: regexp.MustCompile(`^\s`), Match: MatchAfter, MaxLines: DefaultMaxLines, MaxBytes: DefaultMaxBytes})
+	if _, ok := a.Flush(); ok {
+		t.Error("Flush on an empty Aggregator should report ok=false")
+	}
+}
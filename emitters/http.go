@@ -0,0 +1,121 @@
+package emitters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/RedHatInsights/haberdasher/logging"
+)
+
+func init() {
+	logging.Register("http", &HTTPEmitter{})
+}
+
+// HTTPEmitter POSTs log messages to a configurable endpoint, either as
+// newline-delimited JSON (the default) or as the JSON encoding of an
+// OTLP/HTTP logs export request when HABERDASHER_HTTP_FORMAT=otlp, retrying
+// failed requests with exponential backoff. HTTPEmitter implements
+// logging.BatchEmitter so the Pipeline's own batching, retry and
+// dead-lettering apply on top of postWithRetry's per-request retries.
+type HTTPEmitter struct {
+	client   *http.Client
+	endpoint string
+	format   string
+}
+
+// Setup reads HABERDASHER_HTTP_ENDPOINT/HABERDASHER_HTTP_FORMAT.
+func (e *HTTPEmitter) Setup() {
+	e.endpoint = os.Getenv("HABERDASHER_HTTP_ENDPOINT")
+	e.format = os.Getenv("HABERDASHER_HTTP_FORMAT")
+	if e.format == "" {
+		e.format = "ndjson"
+	}
+	e.client = &http.Client{Timeout: 10 * time.Second}
+}
+
+// HandleLogBatch POSTs every envelope in batch as a single request.
+func (e *HTTPEmitter) HandleLogBatch(batch [][]byte) error {
+	var body []byte
+	if e.format == "otlp" {
+		body = toOTLPLogsRequest(batch)
+	} else {
+		body = append(bytes.Join(batch, []byte("\n")), '\n')
+	}
+	return postWithRetry(e.client, e.endpoint, body)
+}
+
+// HandleLogMessage POSTs a single jsonBytes envelope. It's only used if the
+// Pipeline can't batch (it always can for this emitter, via HandleLogBatch
+// above).
+func (e *HTTPEmitter) HandleLogMessage(jsonBytes []byte) error {
+	return e.HandleLogBatch([][]byte{jsonBytes})
+}
+
+// Cleanup is a no-op: HTTPEmitter holds no buffered state of its own.
+func (e *HTTPEmitter) Cleanup() error {
+	return nil
+}
+
+// postWithRetry POSTs body to url, retrying a handful of times with
+// exponential backoff if the request fails or the server returns a 5xx.
+func postWithRetry(client *http.Client, url string, body []byte) error {
+	var lastErr error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; attempt < 5; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 500 {
+			return nil
+		}
+		lastErr = fmt.Errorf("http emitter: server returned %s", resp.Status)
+	}
+	return lastErr
+}
+
+// otlpLogsRequest is a minimal JSON encoding of an OTLP
+// ExportLogsServiceRequest, sufficient for collectors that accept
+// OTLP/HTTP with a JSON content type.
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	Body struct {
+		StringValue string `json:"stringValue"`
+	} `json:"body"`
+}
+
+func toOTLPLogsRequest(batch [][]byte) []byte {
+	records := make([]otlpLogRecord, len(batch))
+	for i, b := range batch {
+		records[i].Body.StringValue = string(b)
+	}
+	req := otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{ScopeLogs: []otlpScopeLogs{{LogRecords: records}}}}}
+	body, _ := json.Marshal(req)
+	return body
+}
@@ -0,0 +1,77 @@
+package emitters
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"strings"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+
+	"github.com/RedHatInsights/haberdasher/logging"
+)
+
+func init() {
+	logging.Register("kafka", &KafkaEmitter{})
+}
+
+// KafkaEmitter produces log messages to a Kafka topic, keyed by
+// HABERDASHER_SERVICE_NAME so records for a given service land on the same
+// partition. Configured via HABERDASHER_KAFKA_BROKERS (comma separated),
+// HABERDASHER_KAFKA_TOPIC, and optionally
+// HABERDASHER_KAFKA_SASL_USERNAME/PASSWORD and HABERDASHER_KAFKA_TLS.
+// KafkaEmitter implements logging.BatchEmitter so the Pipeline's own
+// batching, retry and dead-lettering apply to Kafka sends.
+type KafkaEmitter struct {
+	writer *kafka.Writer
+	key    []byte
+}
+
+// Setup configures a writer for the configured brokers; no connection is
+// made until the first write.
+func (e *KafkaEmitter) Setup() {
+	brokers := strings.Split(os.Getenv("HABERDASHER_KAFKA_BROKERS"), ",")
+	e.writer = &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    os.Getenv("HABERDASHER_KAFKA_TOPIC"),
+		Balancer: &kafka.Hash{},
+	}
+	var tlsConfig *tls.Config
+	if tlsEnabled, _ := os.LookupEnv("HABERDASHER_KAFKA_TLS"); tlsEnabled != "" {
+		tlsConfig = &tls.Config{}
+	}
+	username := os.Getenv("HABERDASHER_KAFKA_SASL_USERNAME")
+	if username != "" || tlsConfig != nil {
+		transport := &kafka.Transport{TLS: tlsConfig}
+		if username != "" {
+			transport.SASL = plain.Mechanism{
+				Username: username,
+				Password: os.Getenv("HABERDASHER_KAFKA_SASL_PASSWORD"),
+			}
+		}
+		e.writer.Transport = transport
+	}
+	e.key = []byte(os.Getenv("HABERDASHER_SERVICE_NAME"))
+}
+
+// HandleLogBatch produces every envelope in batch as a single Kafka write.
+func (e *KafkaEmitter) HandleLogBatch(batch [][]byte) error {
+	msgs := make([]kafka.Message, len(batch))
+	for i, b := range batch {
+		msgs[i] = kafka.Message{Key: e.key, Value: b}
+	}
+	return e.writer.WriteMessages(context.Background(), msgs...)
+}
+
+// HandleLogMessage produces a single jsonBytes envelope. It's only used if
+// the Pipeline can't batch (it always can for this emitter, via
+// HandleLogBatch above).
+func (e *KafkaEmitter) HandleLogMessage(jsonBytes []byte) error {
+	return e.HandleLogBatch([][]byte{jsonBytes})
+}
+
+// Cleanup closes the Kafka writer.
+func (e *KafkaEmitter) Cleanup() error {
+	return e.writer.Close()
+}
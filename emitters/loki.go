@@ -0,0 +1,82 @@
+package emitters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/RedHatInsights/haberdasher/logging"
+)
+
+func init() {
+	logging.Register("loki", &LokiEmitter{})
+}
+
+// LokiEmitter pushes log messages to a Grafana Loki /loki/api/v1/push
+// endpoint as a single stream, labeled from HABERDASHER_LABELS. LokiEmitter
+// implements logging.BatchEmitter so the Pipeline's own batching, retry and
+// dead-lettering apply to Loki pushes.
+type LokiEmitter struct {
+	client   *http.Client
+	endpoint string
+	labels   map[string]string
+}
+
+// Setup reads HABERDASHER_LOKI_ENDPOINT/HABERDASHER_LABELS.
+func (e *LokiEmitter) Setup() {
+	e.endpoint = os.Getenv("HABERDASHER_LOKI_ENDPOINT")
+	e.client = &http.Client{Timeout: 10 * time.Second}
+	e.labels = map[string]string{}
+	if labelsJSON, exists := os.LookupEnv("HABERDASHER_LABELS"); exists {
+		json.Unmarshal([]byte(labelsJSON), &e.labels)
+	}
+}
+
+// lokiPushRequest is the JSON body accepted by Loki's push API.
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// HandleLogBatch pushes every envelope in batch as a single Loki stream.
+func (e *LokiEmitter) HandleLogBatch(batch [][]byte) error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	values := make([][2]string, len(batch))
+	for i, b := range batch {
+		values[i] = [2]string{now, string(b)}
+	}
+	req := lokiPushRequest{Streams: []lokiStream{{Stream: e.labels, Values: values}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("loki emitter: server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// HandleLogMessage pushes a single jsonBytes envelope. It's only used if
+// the Pipeline can't batch (it always can for this emitter, via
+// HandleLogBatch above).
+func (e *LokiEmitter) HandleLogMessage(jsonBytes []byte) error {
+	return e.HandleLogBatch([][]byte{jsonBytes})
+}
+
+// Cleanup is a no-op: LokiEmitter holds no buffered state of its own.
+func (e *LokiEmitter) Cleanup() error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package emitters
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/RedHatInsights/haberdasher/logging"
+)
+
+func init() {
+	logging.Register("stderr", &StderrEmitter{})
+}
+
+// StderrEmitter writes each log message to the wrapper's own stderr,
+// newline-delimited. It's the default emitter and requires no
+// configuration.
+type StderrEmitter struct{}
+
+// Setup is a no-op; StderrEmitter has nothing to initialize.
+func (e *StderrEmitter) Setup() {}
+
+// HandleLogMessage writes jsonBytes to stderr.
+func (e *StderrEmitter) HandleLogMessage(jsonBytes []byte) error {
+	_, err := fmt.Fprintln(os.Stderr, string(jsonBytes))
+	return err
+}
+
+// Cleanup is a no-op; there's nothing to flush or close.
+func (e *StderrEmitter) Cleanup() error {
+	return nil
+}
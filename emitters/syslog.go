@@ -0,0 +1,69 @@
+package emitters
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/RedHatInsights/haberdasher/logging"
+)
+
+func init() {
+	logging.Register("syslog", &SyslogEmitter{})
+}
+
+// SyslogEmitter ships each log message as an RFC5424 syslog message over
+// TCP, UDP or TLS, as selected by HABERDASHER_SYSLOG_NETWORK ("tcp", "udp",
+// or "tls"; default "udp") against HABERDASHER_SYSLOG_ADDR.
+type SyslogEmitter struct {
+	conn    net.Conn
+	appName string
+}
+
+// Setup dials the configured syslog receiver.
+func (e *SyslogEmitter) Setup() {
+	network := os.Getenv("HABERDASHER_SYSLOG_NETWORK")
+	if network == "" {
+		network = "udp"
+	}
+	addr := os.Getenv("HABERDASHER_SYSLOG_ADDR")
+	e.appName = os.Getenv("HABERDASHER_SYSLOG_APP_NAME")
+	if e.appName == "" {
+		e.appName = "haberdasher"
+	}
+
+	var conn net.Conn
+	var err error
+	if network == "tls" {
+		conn, err = tls.Dial("tcp", addr, &tls.Config{})
+	} else {
+		conn, err = net.Dial(network, addr)
+	}
+	if err != nil {
+		log.Println("Error connecting to syslog receiver:", err)
+		return
+	}
+	e.conn = conn
+}
+
+// HandleLogMessage wraps jsonBytes as the MSG of an RFC5424 syslog message
+// and writes it to the open connection.
+func (e *SyslogEmitter) HandleLogMessage(jsonBytes []byte) error {
+	if e.conn == nil {
+		return fmt.Errorf("syslog emitter: not connected")
+	}
+	msg := fmt.Sprintf("<14>1 %s - %s - - - %s\n", time.Now().Format(time.RFC3339), e.appName, jsonBytes)
+	_, err := e.conn.Write([]byte(msg))
+	return err
+}
+
+// Cleanup closes the syslog connection.
+func (e *SyslogEmitter) Cleanup() error {
+	if e.conn == nil {
+		return nil
+	}
+	return e.conn.Close()
+}
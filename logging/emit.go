@@ -49,6 +49,11 @@ type Message struct {
 	Labels map[string]string `json:"labels"`
 	Tags []string `json:"tags"`
 	Message string `json:"message"`
+	Level string `json:"log.level,omitempty"`
+	OriginFileName string `json:"log.origin.file.name,omitempty"`
+	OriginFileLine int `json:"log.origin.file.line,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	ErrorStackTrace string `json:"error.stack_trace,omitempty"`
 }
 
 // Emitters is the registry of Emitter implementers
@@ -59,19 +64,49 @@ func Register(emitterType string, emitter Emitter) {
 	Emitters[emitterType] = emitter
 }
 
-// Emit is launched as a goroutine for individual log lines to be sent
-// concurrently. When it receives a line, it tries to decode it from JSON.
-// If that succeeds, meaning it's already a structured object, we pass it along
-// unmodified. If not, we wrap it in a basic ECS structure.
+// Emit sends a single log record directly to emitter, bypassing any
+// Pipeline. Most callers should submit records to a Pipeline instead so
+// sends are retried and bounded by its worker pool; Emit remains for
+// emitters driven outside of one.
 func Emit(emitter Emitter, logMessage string) {
-	// If the emitted message is JSON, pass it along unmodified
+	EmitRecord(emitter, logMessage, false)
+}
+
+// EmitRecord is like Emit, but additionally marks the record as a stitched
+// multi-line record (e.g. a stack trace) so it is also reported under
+// error.stack_trace, alongside message.
+func EmitRecord(emitter Emitter, logMessage string, isStackTrace bool) {
+	messageToEmit := buildEnvelope(logMessage, isStackTrace)
+	if err := emitter.HandleLogMessage(messageToEmit); err != nil {
+		log.Println("Error emitting message:", messageToEmit, err)
+	}
+}
+
+// buildEnvelope returns the JSON bytes to hand an Emitter for logMessage.
+// If it's already valid JSON, it's passed through unmodified. Otherwise,
+// and if HABERDASHER_INPUT_FORMAT names a known parser, the line is run
+// through it to pull out a level, timestamp and source location; in either
+// case the result is wrapped in a basic ECS envelope, with
+// error.stack_trace populated when isStackTrace is true.
+func buildEnvelope(logMessage string, isStackTrace bool) []byte {
 	var decodedJSON map[string]interface{}
 	messageToEmit := []byte(logMessage)
 	if err := json.Unmarshal(messageToEmit, &decodedJSON); err != nil {
-		m := Message{defaultEcsVersion, time.Now(), defaultLabels, defaultTags, logMessage}
+		m := Message{ECSVersion: defaultEcsVersion, Timestamp: time.Now(), Labels: defaultLabels, Tags: defaultTags, Message: logMessage}
+		if parser, ok := inputParsers[inputFormat]; ok {
+			if parsed, ok := parser(logMessage); ok {
+				m.Timestamp = parsed.Timestamp
+				m.Message = parsed.Message
+				m.Level = parsed.Level
+				m.OriginFileName = parsed.FileName
+				m.OriginFileLine = parsed.FileLine
+				m.Fields = parsed.Fields
+			}
+		}
+		if isStackTrace {
+			m.ErrorStackTrace = logMessage
+		}
 		messageToEmit, _ = json.Marshal(m)
 	}
-	if err := emitter.HandleLogMessage(messageToEmit); err != nil {
-		log.Println("Error emitting message:", messageToEmit, err)
-	}
+	return messageToEmit
 }
\ No newline at end of file
@@ -0,0 +1,212 @@
+package logging
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inputFormat selects how non-JSON lines from the wrapped application are
+// parsed before being wrapped in an ECS envelope. It is read once from
+// HABERDASHER_INPUT_FORMAT; an empty value (the default) preserves the
+// historical behaviour of stuffing the raw line into Message.Message
+// unparsed.
+var inputFormat string
+
+func init() {
+	inputFormat, _ = os.LookupEnv("HABERDASHER_INPUT_FORMAT")
+}
+
+// ParsedLine holds the fields a LineParser was able to pull out of a single
+// line of input, ready to be folded into a Message.
+type ParsedLine struct {
+	Level     string
+	Timestamp time.Time
+	FileName  string
+	FileLine  int
+	Message   string
+	Fields    map[string]string
+}
+
+// LineParser extracts structured fields from a single non-JSON log line. It
+// reports ok=false when the line doesn't match the parser's format, in which
+// case the caller should fall back to treating the line as plain text.
+type LineParser func(line string) (parsed ParsedLine, ok bool)
+
+// inputParsers is the registry of supported HABERDASHER_INPUT_FORMAT values.
+var inputParsers = map[string]LineParser{
+	"logfmt": parseLogfmt,
+	"klog":   parseKlog,
+	"glog":   parseKlog,
+	"syslog": parseSyslog,
+}
+
+// logfmtPairRe matches a single key=value token, where value may be a
+// double-quoted string (with backslash escapes) or a bare, whitespace-free
+// token.
+var logfmtPairRe = regexp.MustCompile(`([^\s=]+)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+// parseLogfmt parses lines of space-separated key=value pairs, e.g. the
+// output of Go's standard "log/slog" or Hashicorp's go-hclog in logfmt mode.
+// The "msg"/"message" and "level"/"lvl"/"severity" keys are pulled out as
+// the message and level respectively; everything else is returned as
+// Fields.
+func parseLogfmt(line string) (ParsedLine, bool) {
+	fields, ok := scanLogfmtPairs(line)
+	if !ok {
+		return ParsedLine{}, false
+	}
+	parsed := ParsedLine{Timestamp: time.Now(), Fields: fields}
+	for _, key := range []string{"level", "lvl", "severity"} {
+		if v, exists := fields[key]; exists {
+			parsed.Level = v
+			delete(fields, key)
+			break
+		}
+	}
+	for _, key := range []string{"msg", "message"} {
+		if v, exists := fields[key]; exists {
+			parsed.Message = v
+			delete(fields, key)
+			break
+		}
+	}
+	if v, exists := fields["ts"]; exists {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			parsed.Timestamp = t
+			delete(fields, "ts")
+		}
+	}
+	if parsed.Message == "" {
+		parsed.Message = line
+	}
+	return parsed, true
+}
+
+// scanLogfmtPairs tokenizes a logfmt-style line into a key/value map. It
+// reports ok=false if the line contains no key=value tokens at all.
+func scanLogfmtPairs(line string) (map[string]string, bool) {
+	matches := logfmtPairRe.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		return nil, false
+	}
+	fields := make(map[string]string, len(matches))
+	for _, m := range matches {
+		key, val := m[1], m[2]
+		if strings.HasPrefix(val, `"`) {
+			if unquoted, err := strconv.Unquote(val); err == nil {
+				val = unquoted
+			}
+		}
+		fields[key] = val
+	}
+	return fields, true
+}
+
+// klogRe matches the glog/klog line header used by most Kubernetes
+// components, e.g. "I0325 12:34:56.789012  1234 file.go:42] msg key=val".
+var klogRe = regexp.MustCompile(`^([IWEF])(\d{2})(\d{2}) (\d{2}):(\d{2}):(\d{2})\.(\d{6})\s+\d+ ([^:]+):(\d+)\] (.*)$`)
+
+var klogSeverities = map[string]string{"I": "info", "W": "warn", "E": "error", "F": "fatal"}
+
+// parseKlog parses the klog/glog line format. The single-letter severity,
+// timestamp (klog omits the year, so the current year is assumed) and
+// source file/line are extracted; any key=value tokens trailing the
+// message are split off into Fields the same way parseLogfmt handles them.
+func parseKlog(rawLine string) (ParsedLine, bool) {
+	m := klogRe.FindStringSubmatch(rawLine)
+	if m == nil {
+		return ParsedLine{}, false
+	}
+	now := time.Now()
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	hour, _ := strconv.Atoi(m[4])
+	minute, _ := strconv.Atoi(m[5])
+	second, _ := strconv.Atoi(m[6])
+	micros, _ := strconv.Atoi(m[7])
+	fileLine, _ := strconv.Atoi(m[9])
+	message, fields := splitKlogTail(m[10])
+	return ParsedLine{
+		Level:     klogSeverities[m[1]],
+		Timestamp: time.Date(now.Year(), time.Month(month), day, hour, minute, second, micros*1000, now.Location()),
+		FileName:  m[8],
+		FileLine:  fileLine,
+		Message:   message,
+		Fields:    fields,
+	}, true
+}
+
+// splitKlogTail separates a klog message body from any trailing
+// "key=value" tokens klog.InfoS-style logging appends after the free-text
+// message.
+func splitKlogTail(s string) (string, map[string]string) {
+	loc := logfmtPairRe.FindStringIndex(s)
+	if loc == nil {
+		return s, nil
+	}
+	fields, ok := scanLogfmtPairs(s[loc[0]:])
+	if !ok {
+		return s, nil
+	}
+	return strings.TrimSpace(s[:loc[0]]), fields
+}
+
+// syslogSeverities maps an RFC5424 severity (the low 3 bits of PRI) to its
+// keyword.
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslog5424Re matches an RFC5424 message: "<PRI>1 TIMESTAMP HOSTNAME
+// APP-NAME PROCID MSGID STRUCTURED-DATA MSG".
+var syslog5424Re = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\S+) (\S+) (?:-|\[.*\]) ?(.*)$`)
+
+// syslog3164Re matches a legacy RFC3164 message: "<PRI>Mon dd hh:mm:ss
+// hostname tag[pid]: msg".
+var syslog3164Re = regexp.MustCompile(`^<(\d+)>(\w{3}\s+\d{1,2} \d{2}:\d{2}:\d{2}) (\S+) ([^:\[]+)(?:\[(\d+)\])?: (.*)$`)
+
+// parseSyslog parses RFC5424 and, failing that, legacy RFC3164 syslog
+// messages. The PRI severity becomes Level; hostname/app-name/procid are
+// returned as Fields under a "syslog." prefix.
+func parseSyslog(line string) (ParsedLine, bool) {
+	if m := syslog5424Re.FindStringSubmatch(line); m != nil {
+		pri, _ := strconv.Atoi(m[1])
+		ts, err := time.Parse(time.RFC3339Nano, m[2])
+		if err != nil {
+			ts = time.Now()
+		}
+		return ParsedLine{
+			Level:     syslogSeverities[pri%8],
+			Timestamp: ts,
+			Message:   m[7],
+			Fields: map[string]string{
+				"syslog.hostname": m[3],
+				"syslog.appname":  m[4],
+				"syslog.procid":   m[5],
+				"syslog.msgid":    m[6],
+			},
+		}, true
+	}
+	if m := syslog3164Re.FindStringSubmatch(line); m != nil {
+		pri, _ := strconv.Atoi(m[1])
+		now := time.Now()
+		ts, err := time.Parse("Jan  2 15:04:05", m[2])
+		if err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+		} else {
+			ts = now
+		}
+		return ParsedLine{
+			Level:     syslogSeverities[pri%8],
+			Timestamp: ts,
+			Message:   m[6],
+			Fields: map[string]string{
+				"syslog.hostname": m[3],
+				"syslog.appname":  m[4],
+				"syslog.procid":   m[5],
+			},
+		}, true
+	}
+	return ParsedLine{}, false
+}
@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	cases := []struct {
+		name      string
+		line      string
+		ok        bool
+		wantLevel string
+		wantMsg   string
+		wantField string
+		wantValue string
+	}{
+		{
+			name:      "level and msg extracted",
+			line:      `level=info msg="starting up" pid=42`,
+			ok:        true,
+			wantLevel: "info",
+			wantMsg:   "starting up",
+			wantField: "pid",
+			wantValue: "42",
+		},
+		{
+			name:      "lvl/message aliases",
+			line:      `lvl=warn message=retrying attempt=3`,
+			ok:        true,
+			wantLevel: "warn",
+			wantMsg:   "retrying",
+			wantField: "attempt",
+			wantValue: "3",
+		},
+		{
+			name: "no key=value pairs falls back",
+			line: "just a plain line",
+			ok:   false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, ok := parseLogfmt(tc.line)
+			if ok != tc.ok {
+				t.Fatalf("ok = %v, want %v", ok, tc.ok)
+			}
+			if !tc.ok {
+				return
+			}
+			if parsed.Level != tc.wantLevel {
+				t.Errorf("Level = %q, want %q", parsed.Level, tc.wantLevel)
+			}
+			if parsed.Message != tc.wantMsg {
+				t.Errorf("Message = %q, want %q", parsed.Message, tc.wantMsg)
+			}
+			if tc.wantField != "" && parsed.Fields[tc.wantField] != tc.wantValue {
+				t.Errorf("Fields[%q] = %q, want %q", tc.wantField, parsed.Fields[tc.wantField], tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestParseKlog(t *testing.T) {
+	line := "I0325 12:34:56.789012    1234 controller.go:42] reconciling object name=foo"
+	parsed, ok := parseKlog(line)
+	if !ok {
+		t.Fatalf("parseKlog(%q) returned ok=false", line)
+	}
+	if parsed.Level != "info" {
+		t.Errorf("Level = %q, want %q", parsed.Level, "info")
+	}
+	if parsed.FileName != "controller.go" || parsed.FileLine != 42 {
+		t.Errorf("FileName/FileLine = %q:%d, want %q:%d", parsed.FileName, parsed.FileLine, "controller.go", 42)
+	}
+	if parsed.Message != "reconciling object" {
+		t.Errorf("Message = %q, want %q", parsed.Message, "reconciling object")
+	}
+	if parsed.Fields["name"] != "foo" {
+		t.Errorf("Fields[name] = %q, want %q", parsed.Fields["name"], "foo")
+	}
+	wantTime := time.Date(time.Now().Year(), time.March, 25, 12, 34, 56, 789012000, time.Now().Location())
+	if !parsed.Timestamp.Equal(wantTime) {
+		t.Errorf("Timestamp = %v, want %v", parsed.Timestamp, wantTime)
+	}
+
+	if _, ok := parseKlog("not a klog line"); ok {
+		t.Error("parseKlog matched a non-klog line")
+	}
+}
+
+func TestParseSyslog(t *testing.T) {
+	t.Run("rfc5424", func(t *testing.T) {
+		line := `<34>1 2003-10-11T22:14:15.003Z mymachine su 123 ID47 - 'su root' failed for lonvick`
+		parsed, ok := parseSyslog(line)
+		if !ok {
+			t.Fatalf("parseSyslog(%q) returned ok=false", line)
+		}
+		if parsed.Level != "crit" {
+			t.Errorf("Level = %q, want %q", parsed.Level, "crit")
+		}
+		if parsed.Message != "'su root' failed for lonvick" {
+			t.Errorf("Message = %q, want %q", parsed.Message, "'su root' failed for lonvick")
+		}
+		if parsed.Fields["syslog.hostname"] != "mymachine" {
+			t.Errorf("Fields[syslog.hostname] = %q, want %q", parsed.Fields["syslog.hostname"], "mymachine")
+		}
+	})
+
+	t.Run("rfc3164", func(t *testing.T) {
+		line := `<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick`
+		parsed, ok := parseSyslog(line)
+		if !ok {
+			t.Fatalf("parseSyslog(%q) returned ok=false", line)
+		}
+		if parsed.Level != "crit" {
+			t.Errorf("Level = %q, want %q", parsed.Level, "crit")
+		}
+		if parsed.Fields["syslog.appname"] != "su" || parsed.Fields["syslog.procid"] != "123" {
+			t.Errorf("Fields = %+v, want appname=su procid=123", parsed.Fields)
+		}
+	})
+
+	t.Run("unrecognized", func(t *testing.T) {
+		if _, ok := parseSyslog("not a syslog line"); ok {
+			t.Error("parseSyslog matched a non-syslog line")
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/haberdasher/middleware"
+)
+
+// OverflowPolicy controls what a Pipeline does with a new record when its
+// queue is already full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes Submit block until the queue has room.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest discards the record being submitted.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest discards the oldest queued record to make room.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowSample keeps roughly 1% of records that arrive while the
+	// queue is full, rather than dropping all of them.
+	OverflowSample OverflowPolicy = "sample"
+)
+
+// maxSendAttempts bounds how many times in all (the first try plus its
+// retries) the pipeline attempts a send before dead-lettering the record to
+// stderr.
+const maxSendAttempts = 5
+
+// maxBatchDrain bounds how many queued records a worker gathers into a
+// single HandleLogBatch call.
+const maxBatchDrain = 100
+
+// PipelineConfig controls the worker pool that drains a Pipeline's queue.
+type PipelineConfig struct {
+	Workers     int
+	QueueSize   int
+	Overflow    OverflowPolicy
+	MetricsAddr string
+}
+
+// PipelineConfigFromEnv builds a PipelineConfig from HABERDASHER_WORKERS,
+// HABERDASHER_QUEUE_SIZE, HABERDASHER_OVERFLOW_POLICY and
+// HABERDASHER_METRICS_ADDR.
+func PipelineConfigFromEnv() PipelineConfig {
+	cfg := PipelineConfig{Workers: 4, QueueSize: 1000, Overflow: OverflowBlock}
+	if v, exists := os.LookupEnv("HABERDASHER_WORKERS"); exists {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Workers = n
+		}
+	}
+	if v, exists := os.LookupEnv("HABERDASHER_QUEUE_SIZE"); exists {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.QueueSize = n
+		}
+	}
+	if v, exists := os.LookupEnv("HABERDASHER_OVERFLOW_POLICY"); exists {
+		switch OverflowPolicy(v) {
+		case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample:
+			cfg.Overflow = OverflowPolicy(v)
+		}
+	}
+	cfg.MetricsAddr = os.Getenv("HABERDASHER_METRICS_ADDR")
+	return cfg
+}
+
+// BatchEmitter is implemented by emitters that can accept several envelopes
+// in a single call. A Pipeline prefers HandleLogBatch over calling
+// HandleLogMessage once per record when an emitter supports it.
+type BatchEmitter interface {
+	HandleLogBatch(batch [][]byte) error
+}
+
+type queuedRecord struct {
+	text         string
+	isStackTrace bool
+}
+
+// pipelineMetrics are the Prometheus-style counters a Pipeline exposes via
+// its optional metrics endpoint.
+type pipelineMetrics struct {
+	linesIn  uint64
+	linesOut uint64
+	dropped  uint64
+	retries  uint64
+}
+
+// Pipeline is a bounded worker pool that sits between the supervisor's read
+// loop and an Emitter: Submit enqueues a record, and a fixed number of worker
+// goroutines drain the queue, building the ECS envelope, retrying failed
+// sends with exponential backoff plus jitter, and dead-lettering to stderr
+// after maxSendAttempts. It replaces spawning an unbounded goroutine per
+// line.
+type Pipeline struct {
+	emitter Emitter
+	cfg     PipelineConfig
+	queue   chan queuedRecord
+	wg      sync.WaitGroup
+	metrics pipelineMetrics
+	chain   []middleware.Middleware
+
+	// closeMu guards against Submit sending on queue after Close has
+	// closed it: Submit holds a read lock for the duration of its send,
+	// and Close takes the write lock - which waits for any in-flight
+	// Submit calls to finish - before closing queue.
+	closeMu   sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewPipeline starts cfg.Workers worker goroutines draining into emitter,
+// and an optional metrics HTTP server if cfg.MetricsAddr is set. Every
+// envelope is run through the middleware chain configured by
+// HABERDASHER_PIPELINE before reaching emitter.
+func NewPipeline(emitter Emitter, cfg PipelineConfig) *Pipeline {
+	p := &Pipeline{
+		emitter: emitter,
+		cfg:     cfg,
+		queue:   make(chan queuedRecord, cfg.QueueSize),
+		chain:   middleware.ChainFromEnv(),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	if p.cfg.MetricsAddr != "" {
+		p.serveMetrics(p.cfg.MetricsAddr)
+	}
+	return p
+}
+
+// Submit enqueues a record to be emitted, applying the Pipeline's
+// OverflowPolicy if the queue is full. Submit is safe to call concurrently
+// with Close: once Close has run, Submit silently drops the record instead
+// of sending on the now-closed queue.
+func (p *Pipeline) Submit(text string, isStackTrace bool) {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		atomic.AddUint64(&p.metrics.dropped, 1)
+		return
+	}
+	atomic.AddUint64(&p.metrics.linesIn, 1)
+	rec := queuedRecord{text: text, isStackTrace: isStackTrace}
+	switch p.cfg.Overflow {
+	case OverflowDropNewest:
+		select {
+		case p.queue <- rec:
+		default:
+			atomic.AddUint64(&p.metrics.dropped, 1)
+		}
+	case OverflowDropOldest:
+		select {
+		case p.queue <- rec:
+		default:
+			select {
+			case <-p.queue:
+				atomic.AddUint64(&p.metrics.dropped, 1)
+			default:
+			}
+			select {
+			case p.queue <- rec:
+			default:
+				atomic.AddUint64(&p.metrics.dropped, 1)
+			}
+		}
+	case OverflowSample:
+		select {
+		case p.queue <- rec:
+		default:
+			if rand.Intn(100) != 0 {
+				atomic.AddUint64(&p.metrics.dropped, 1)
+				return
+			}
+			// Make room for the sampled record by evicting the oldest one.
+			select {
+			case <-p.queue:
+				atomic.AddUint64(&p.metrics.dropped, 1)
+			default:
+			}
+			select {
+			case p.queue <- rec:
+			default:
+				atomic.AddUint64(&p.metrics.dropped, 1)
+			}
+		}
+	default: // OverflowBlock
+		p.queue <- rec
+	}
+}
+
+// Close stops accepting new work on the queue and waits for every worker to
+// drain what's already buffered. Call it before the emitter's own Cleanup
+// so nothing queued is lost on shutdown. Close is idempotent and safe to
+// call more than once; only the first call does any work.
+func (p *Pipeline) Close() {
+	p.closeOnce.Do(func() {
+		p.closeMu.Lock()
+		p.closed = true
+		p.closeMu.Unlock()
+		close(p.queue)
+		p.wg.Wait()
+	})
+}
+
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+	batchEmitter, supportsBatch := p.emitter.(BatchEmitter)
+	for rec := range p.queue {
+		batch := []queuedRecord{rec}
+	drain:
+		for len(batch) < maxBatchDrain {
+			select {
+			case more, open := <-p.queue:
+				if !open {
+					break drain
+				}
+				batch = append(batch, more)
+			default:
+				break drain
+			}
+		}
+		atomic.AddUint64(&p.metrics.linesOut, uint64(len(batch)))
+		envelopes := make([][]byte, 0, len(batch))
+		for _, r := range batch {
+			envelope := buildEnvelope(r.text, r.isStackTrace)
+			if out, keep := middleware.Apply(p.chain, envelope); keep {
+				envelopes = append(envelopes, out)
+			}
+		}
+		if len(envelopes) == 0 {
+			continue
+		}
+		if supportsBatch {
+			p.sendBatch(batchEmitter, envelopes)
+		} else {
+			for _, envelope := range envelopes {
+				p.send(envelope)
+			}
+		}
+	}
+}
+
+func (p *Pipeline) send(envelope []byte) {
+	if p.retry(func() error { return p.emitter.HandleLogMessage(envelope) }) {
+		return
+	}
+	deadLetter(envelope)
+}
+
+func (p *Pipeline) sendBatch(emitter BatchEmitter, batch [][]byte) {
+	if p.retry(func() error { return emitter.HandleLogBatch(batch) }) {
+		return
+	}
+	for _, envelope := range batch {
+		deadLetter(envelope)
+	}
+}
+
+// retry calls send up to maxSendAttempts times in all, retrying on error
+// with exponential backoff plus jitter. It reports whether send eventually
+// succeeded.
+func (p *Pipeline) retry(send func() error) bool {
+	backoff := 250 * time.Millisecond
+	for attempt := 0; attempt < maxSendAttempts; attempt++ {
+		if err := send(); err == nil {
+			return true
+		} else if attempt == 0 {
+			log.Println("Error emitting, will retry:", err)
+		}
+		if attempt == maxSendAttempts-1 {
+			break
+		}
+		atomic.AddUint64(&p.metrics.retries, 1)
+		jitter := time.Duration(rand.Int63n(int64(backoff)))
+		time.Sleep(backoff + jitter)
+		backoff *= 2
+	}
+	return false
+}
+
+// deadLetter is the last resort for a record that couldn't be emitted after
+// maxSendAttempts: write it to our own stderr so it isn't silently lost.
+func deadLetter(envelope []byte) {
+	fmt.Fprintln(os.Stderr, string(envelope))
+}
+
+// serveMetrics starts an HTTP server exposing Prometheus-style counters at
+// /metrics on addr.
+func (p *Pipeline) serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "haberdasher_lines_in_total %d\n", atomic.LoadUint64(&p.metrics.linesIn))
+		fmt.Fprintf(w, "haberdasher_lines_out_total %d\n", atomic.LoadUint64(&p.metrics.linesOut))
+		fmt.Fprintf(w, "haberdasher_dropped_total %d\n", atomic.LoadUint64(&p.metrics.dropped))
+		fmt.Fprintf(w, "haberdasher_retries_total %d\n", atomic.LoadUint64(&p.metrics.retries))
+		fmt.Fprintf(w, "haberdasher_queue_depth %d\n", len(p.queue))
+	})
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("Error serving metrics:", err)
+		}
+	}()
+}
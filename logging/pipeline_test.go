@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPipeline(overflow OverflowPolicy, queueSize int) *Pipeline {
+	// Workers: 0 means nothing ever drains the queue, so overflow
+	// behavior can be observed deterministically instead of racing a
+	// worker goroutine.
+	cfg := PipelineConfig{Workers: 0, QueueSize: queueSize, Overflow: overflow}
+	return NewPipeline(nil, cfg)
+}
+
+func TestPipelineOverflowDropNewest(t *testing.T) {
+	p := newTestPipeline(OverflowDropNewest, 2)
+	p.Submit("a", false)
+	p.Submit("b", false)
+	p.Submit("c", false) // queue is full; c should be dropped
+
+	if got := len(p.queue); got != 2 {
+		t.Fatalf("queue length = %d, want 2", got)
+	}
+	if got := p.metrics.dropped; got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+	if got := p.metrics.linesIn; got != 3 {
+		t.Errorf("linesIn = %d, want 3", got)
+	}
+}
+
+func TestPipelineOverflowDropOldest(t *testing.T) {
+	p := newTestPipeline(OverflowDropOldest, 2)
+	p.Submit("a", false)
+	p.Submit("b", false)
+	p.Submit("c", false) // queue is full; a should be evicted to make room
+
+	if got := len(p.queue); got != 2 {
+		t.Fatalf("queue length = %d, want 2", got)
+	}
+	first := <-p.queue
+	if first.text != "b" {
+		t.Errorf("oldest remaining record = %q, want %q", first.text, "b")
+	}
+	if got := p.metrics.dropped; got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+}
+
+func TestPipelineOverflowSample(t *testing.T) {
+	p := newTestPipeline(OverflowSample, 1)
+	p.Submit("a", false) // fills the queue
+
+	const attempts = 500
+	for i := 0; i < attempts; i++ {
+		p.Submit("overflow", false)
+	}
+
+	// OverflowSample keeps roughly 1% of records submitted while the
+	// queue is full; with 500 attempts almost all should be dropped.
+	if p.metrics.dropped == 0 {
+		t.Error("dropped = 0, want most of the overflow records to be dropped")
+	}
+	if p.metrics.dropped > attempts {
+		t.Errorf("dropped = %d, want at most %d", p.metrics.dropped, attempts)
+	}
+}
+
+func TestPipelineOverflowBlock(t *testing.T) {
+	p := newTestPipeline(OverflowBlock, 1)
+	p.Submit("a", false) // fills the queue
+
+	submitted := make(chan struct{})
+	go func() {
+		p.Submit("b", false) // should block until the queue has room
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit returned before the queue had room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-p.queue // make room, as a worker would
+
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("Submit did not unblock once the queue had room")
+	}
+}
+
+func TestRetryDoesNotCountOrSleepAfterFinalAttempt(t *testing.T) {
+	p := newTestPipeline(OverflowBlock, 1)
+	attempts := 0
+	alwaysFails := func() error {
+		attempts++
+		return errors.New("boom")
+	}
+
+	if p.retry(alwaysFails) {
+		t.Fatal("retry should report failure when send always errors")
+	}
+	if attempts != maxSendAttempts {
+		t.Errorf("send was called %d times, want %d", attempts, maxSendAttempts)
+	}
+	// The final, unretried attempt shouldn't count towards retries - only
+	// the attempts that were actually followed by a backoff+retry should.
+	if got := p.metrics.retries; got != maxSendAttempts-1 {
+		t.Errorf("retries = %d, want %d", got, maxSendAttempts-1)
+	}
+}
+
+func TestPipelineSubmitAfterClose(t *testing.T) {
+	p := newTestPipeline(OverflowBlock, 1)
+	p.Close()
+
+	// Submit must not panic by sending on the now-closed queue, and
+	// should count the record as dropped instead.
+	p.Submit("a", false)
+	if got := p.metrics.dropped; got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+
+	// Close must be safe to call more than once.
+	p.Close()
+}
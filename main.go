@@ -3,40 +3,51 @@ package main
 import (
 	"log"
 	"os"
-	"os/exec"
-	"bufio"
 	"os/signal"
 	"syscall"
+	"time"
+
 	reaper "github.com/ramr/go-reaper"
 	"github.com/RedHatInsights/haberdasher/logging"
+	"github.com/RedHatInsights/haberdasher/supervisor"
 	_ "github.com/RedHatInsights/haberdasher/emitters"
 )
 
-// If running as PID1, we need to actively catch and handle any shutdown signals
-// So with this handler, we pass the signal along to the subprocess we spawned
-// and allow our emitters' buffers to flush before exiting
-func signalHandler(pid *int, emitter logging.Emitter, signalChan chan os.Signal) {
-	var signalToSendChild syscall.Signal = syscall.SIGHUP
+// terminatingSignals are the signals that should trigger a graceful
+// haberdasher shutdown, as opposed to merely being forwarded to the child.
+var terminatingSignals = map[os.Signal]bool{
+	syscall.SIGINT:  true,
+	syscall.SIGHUP:  true,
+	syscall.SIGTERM: true,
+}
+
+// If running as PID1, we need to actively catch and handle any shutdown
+// signals. This handler forwards every signal we catch to the subprocess we
+// spawned; it never touches the pipeline or emitter itself. main is the sole
+// owner of the drain-and-cleanup sequence, so there's only ever one caller
+// of pipeline.Close/emitter.Cleanup. For a terminating signal, this handler
+// instead acts as a watchdog: if main hasn't finished shutting down within
+// shutdownTimeout, it force-exits so a wedged subprocess or emitter can't
+// hang haberdasher forever.
+func signalHandler(sup *supervisor.Supervisor, shutdownTimeout time.Duration, done chan struct{}, signalChan chan os.Signal) {
 	for {
 		signalReceived := <-signalChan
 		log.Println("Signal received:", signalReceived)
-		switch signalReceived {
-		case syscall.SIGHUP:
-			signalToSendChild = syscall.SIGHUP
-		case syscall.SIGINT:
-			signalToSendChild = syscall.SIGINT
-		case syscall.SIGTERM:
-			signalToSendChild = syscall.SIGTERM
-		case syscall.SIGKILL:
-			signalToSendChild = syscall.SIGKILL
+		sig, ok := signalReceived.(syscall.Signal)
+		if !ok {
+			continue
 		}
-		log.Println("Sending signal to", *pid)
-		syscall.Kill(*pid, signalToSendChild)
-		log.Println("Trigering emitter shutdown")
-		if err := emitter.Cleanup(); err != nil {
-			log.Println("Error cleaning up emitter:", err)
+		sup.Signal(sig)
+		if !terminatingSignals[signalReceived] {
+			continue
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(shutdownTimeout):
+			log.Println("Timed out waiting for pipeline to drain, exiting")
+			os.Exit(128 + int(sig))
 		}
-		os.Exit(0)
 	}
 }
 
@@ -50,39 +61,33 @@ func main() {
 	}
 	log.Println("Configured emitter:", emitterName)
 	emitter := logging.Emitters[emitterName]
+	pipeline := logging.NewPipeline(emitter, logging.PipelineConfigFromEnv())
 
 	// Reap any zombie children - see: https://github.com/ramr/go-reaper/
 	go reaper.Reap()
-	// Until we start the subprocess, populate the pid variable with something,
-	// in case the signal handler gets fired before we've started it
-	subcmdPid := -1
-	// Spawn a handler for any termination signals
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGKILL)
-	go signalHandler(&subcmdPid, emitter, signalChan)
 
 	// If our selected emitter requires any initialization, do it
 	emitter.Setup()
 
-	subcmdBin := os.Args[1]
-	subcmdArgs := os.Args[2:len(os.Args)]
-	subcmd := exec.Command(subcmdBin, subcmdArgs...)
-	// pass through stdout, but capture stderr
-	subcmd.Stdout = os.Stdout
-	subcmdErr, err := subcmd.StderrPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	scanner := bufio.NewScanner(subcmdErr)
+	supCfg := supervisor.ConfigFromEnv()
+	sup := supervisor.New(supCfg, os.Args[1], os.Args[2:len(os.Args)], pipeline)
 
-	if err := subcmd.Start(); err != nil {
-		log.Fatal(err)
-	}
-	subcmdPid = subcmd.Process.Pid
+	// Spawn a handler for any termination signals. SIGKILL is deliberately
+	// not included here - it can't be caught, so listing it in
+	// signal.Notify is a no-op.
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGWINCH, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+	go signalHandler(sup, supCfg.ShutdownTimeout, done, signalChan)
 
-	for scanner.Scan() {
-		go func() {
-			logging.Emit(emitter, scanner.Text())
-		}()
+	exitCode := sup.Run()
+
+	log.Println("Draining pipeline")
+	pipeline.Close()
+	log.Println("Triggering emitter shutdown")
+	if err := emitter.Cleanup(); err != nil {
+		log.Println("Error cleaning up emitter:", err)
 	}
-}
\ No newline at end of file
+	close(done)
+	os.Exit(exitCode)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+func init() {
+	Register("enrich", &Enricher{})
+}
+
+// containerIDRe matches the 64-character hex container ID most container
+// runtimes assign to the cgroup path recorded in /proc/self/cgroup.
+var containerIDRe = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// Enricher adds host.name, container.id and process.pid to every ECS
+// envelope, so downstream consumers don't need to separately correlate
+// which host/container/process a record came from. It only does so when
+// explicitly enabled via HABERDASHER_ENRICH, so upgrading haberdasher
+// doesn't silently change the output of existing users who haven't opted
+// in.
+type Enricher struct {
+	enabled     bool
+	hostname    string
+	containerID string
+	pid         int
+}
+
+// Setup reads HABERDASHER_ENRICH; the enricher is a no-op unless it's set
+// to a true value. When enabled, it resolves the host name, container ID
+// and PID once at startup, since none of these change for haberdasher's
+// lifetime.
+func (e *Enricher) Setup() {
+	e.enabled, _ = strconv.ParseBool(os.Getenv("HABERDASHER_ENRICH"))
+	if !e.enabled {
+		return
+	}
+	e.hostname, _ = os.Hostname()
+	e.containerID = readContainerID()
+	e.pid = os.Getpid()
+}
+
+// Process adds host.name, container.id and process.pid to envelope. If
+// envelope isn't a JSON object, it's passed through unmodified.
+func (e *Enricher) Process(envelope []byte) ([]byte, bool) {
+	if !e.enabled {
+		return envelope, true
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(envelope, &decoded); err != nil {
+		return envelope, true
+	}
+	if e.hostname != "" {
+		decoded["host.name"] = e.hostname
+	}
+	if e.containerID != "" {
+		decoded["container.id"] = e.containerID
+	}
+	decoded["process.pid"] = e.pid
+	out, err := json.Marshal(decoded)
+	if err != nil {
+		return envelope, true
+	}
+	return out, true
+}
+
+// readContainerID parses /proc/self/cgroup for a container ID; it returns
+// "" outside a container (or on any other read/parse failure).
+func readContainerID() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	return containerIDRe.FindString(string(data))
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEnricherNoopWhenDisabled(t *testing.T) {
+	e := &Enricher{}
+	envelope := []byte(`{"message":"hi"}`)
+	out, keep := e.Process(envelope)
+	if !keep {
+		t.Fatal("Enricher should never drop a record")
+	}
+	if string(out) != string(envelope) {
+		t.Errorf("disabled Enricher modified the envelope: got %q, want %q", out, envelope)
+	}
+}
+
+func TestEnricherAddsFieldsWhenEnabled(t *testing.T) {
+	e := &Enricher{enabled: true, hostname: "host1", containerID: "abc123", pid: 42}
+	out, keep := e.Process([]byte(`{"message":"hi"}`))
+	if !keep {
+		t.Fatal("Enricher should never drop a record")
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("output isn't valid JSON: %v", err)
+	}
+	if decoded["host.name"] != "host1" {
+		t.Errorf("host.name = %v, want %q", decoded["host.name"], "host1")
+	}
+	if decoded["container.id"] != "abc123" {
+		t.Errorf("container.id = %v, want %q", decoded["container.id"], "abc123")
+	}
+	if decoded["process.pid"] != float64(42) {
+		t.Errorf("process.pid = %v, want %v", decoded["process.pid"], 42)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+func init() {
+	Register("level", &LevelFilter{})
+}
+
+// levelRank orders known severities from least to most severe, so
+// HABERDASHER_MIN_LEVEL can be compared against a record's log.level
+// regardless of which vocabulary (syslog, klog, etc.) produced it.
+var levelRank = map[string]int{
+	"debug":   0,
+	"info":    1,
+	"notice":  1,
+	"warn":    2,
+	"warning": 2,
+	"err":     3,
+	"error":   3,
+	"crit":    4,
+	"alert":   4,
+	"emerg":   4,
+	"fatal":   4,
+}
+
+// LevelFilter drops records whose log.level ranks below HABERDASHER_MIN_LEVEL.
+// Records with no recognizable level are always kept, since there's nothing
+// to judge severity against.
+type LevelFilter struct {
+	min     int
+	enabled bool
+}
+
+// Setup reads HABERDASHER_MIN_LEVEL; the filter is a no-op if it's unset or
+// not a recognized level name.
+func (f *LevelFilter) Setup() {
+	minLevel, exists := os.LookupEnv("HABERDASHER_MIN_LEVEL")
+	if !exists || minLevel == "" {
+		return
+	}
+	rank, ok := levelRank[strings.ToLower(minLevel)]
+	if !ok {
+		log.Println("HABERDASHER_MIN_LEVEL not recognized, ignoring:", minLevel)
+		return
+	}
+	f.min = rank
+	f.enabled = true
+}
+
+// Process keeps envelope unmodified, reporting keep=false if its log.level
+// ranks below the configured minimum.
+func (f *LevelFilter) Process(envelope []byte) ([]byte, bool) {
+	if !f.enabled {
+		return envelope, true
+	}
+	rank, ok := levelRank[strings.ToLower(extractLevel(envelope))]
+	if !ok {
+		return envelope, true
+	}
+	return envelope, rank >= f.min
+}
@@ -0,0 +1,85 @@
+// Package middleware implements a pluggable chain of transforms that run on
+// each ECS envelope between logging.buildEnvelope and the configured
+// Emitter, so behavior like level filtering, sampling, redaction and field
+// enrichment can be composed without touching emitters themselves.
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// Middleware transforms or filters a single ECS envelope. Process returns
+// the (possibly modified) envelope and keep=false if the record should be
+// dropped entirely, e.g. because it was filtered by level or sampled out.
+type Middleware interface {
+	Setup()
+	Process(envelope []byte) (out []byte, keep bool)
+}
+
+// registry is the set of Middleware implementers available to
+// HABERDASHER_PIPELINE, keyed by name.
+var registry = make(map[string]Middleware)
+
+// Register makes a named Middleware available to HABERDASHER_PIPELINE.
+func Register(name string, m Middleware) {
+	registry[name] = m
+}
+
+// defaultOrder is used when HABERDASHER_PIPELINE is unset: filtering and
+// sampling run first so dropped records skip the cost of redaction and
+// enrichment, and enrichment runs last so its added fields aren't
+// themselves redacted. Like every middleware here, each stage no-ops
+// unless its own config var is set, so an unset HABERDASHER_PIPELINE is
+// safe for existing users to run with unchanged.
+var defaultOrder = []string{"level", "sampling", "redact", "enrich"}
+
+// ChainFromEnv builds an ordered chain of middleware from
+// HABERDASHER_PIPELINE, a comma-separated list of registered middleware
+// names, calling Setup on each. An unset or empty HABERDASHER_PIPELINE runs
+// every built-in middleware in defaultOrder.
+func ChainFromEnv() []Middleware {
+	names := defaultOrder
+	if spec, exists := os.LookupEnv("HABERDASHER_PIPELINE"); exists && spec != "" {
+		names = nil
+		for _, name := range strings.Split(spec, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	chain := make([]Middleware, 0, len(names))
+	for _, name := range names {
+		m, ok := registry[name]
+		if !ok {
+			log.Println("Unknown middleware in HABERDASHER_PIPELINE:", name)
+			continue
+		}
+		m.Setup()
+		chain = append(chain, m)
+	}
+	return chain
+}
+
+// Apply runs envelope through chain in order, stopping early if any stage
+// drops the record.
+func Apply(chain []Middleware, envelope []byte) ([]byte, bool) {
+	for _, m := range chain {
+		var keep bool
+		envelope, keep = m.Process(envelope)
+		if !keep {
+			return nil, false
+		}
+	}
+	return envelope, true
+}
+
+// extractLevel pulls the "log.level" field out of an ECS envelope, the same
+// flat dotted key logging.Message uses.
+func extractLevel(envelope []byte) string {
+	var decoded struct {
+		Level string `json:"log.level"`
+	}
+	json.Unmarshal(envelope, &decoded)
+	return decoded.Level
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register("redact", &Redactor{})
+}
+
+// redactedToken replaces a value matched by a HABERDASHER_REDACT regex
+// pattern (as opposed to a field-name match, which is hashed instead so two
+// equal redacted values can still be correlated).
+const redactedToken = "[REDACTED]"
+
+// identifierRe distinguishes a plain field name from a regex pattern among
+// HABERDASHER_REDACT entries.
+var identifierRe = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// Redactor removes sensitive data from every field of an ECS envelope,
+// configured via HABERDASHER_REDACT: a JSON array mixing exact field names
+// (e.g. "password", "authorization"), which are hashed wherever they
+// appear, and regex patterns (e.g. a PII pattern), which are matched
+// against string values and replaced with a fixed token.
+type Redactor struct {
+	fieldNames map[string]bool
+	patterns   []*regexp.Regexp
+}
+
+// Setup parses HABERDASHER_REDACT; the redactor is a no-op if it's unset.
+func (r *Redactor) Setup() {
+	spec := os.Getenv("HABERDASHER_REDACT")
+	if spec == "" {
+		return
+	}
+	var entries []string
+	if err := json.Unmarshal([]byte(spec), &entries); err != nil {
+		log.Println("HABERDASHER_REDACT must be a JSON array of field names/regexes:", err)
+		return
+	}
+	r.fieldNames = make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if identifierRe.MatchString(entry) {
+			r.fieldNames[strings.ToLower(entry)] = true
+			continue
+		}
+		pattern, err := regexp.Compile(entry)
+		if err != nil {
+			log.Println("Invalid HABERDASHER_REDACT pattern, skipping:", entry, err)
+			continue
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+}
+
+// Process redacts matching fields and patterns throughout envelope. If
+// envelope isn't a JSON object, it's passed through unmodified.
+func (r *Redactor) Process(envelope []byte) ([]byte, bool) {
+	if len(r.fieldNames) == 0 && len(r.patterns) == 0 {
+		return envelope, true
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(envelope, &decoded); err != nil {
+		return envelope, true
+	}
+	for key, val := range decoded {
+		decoded[key] = r.redactValue(key, val)
+	}
+	out, err := json.Marshal(decoded)
+	if err != nil {
+		return envelope, true
+	}
+	return out, true
+}
+
+// redactValue recurses through val, hashing it if key names a configured
+// sensitive field, or, for string leaves, replacing any substring matching
+// a configured pattern with redactedToken.
+func (r *Redactor) redactValue(key string, val interface{}) interface{} {
+	if r.fieldNames[strings.ToLower(key)] {
+		return hashValue(val)
+	}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for k, nested := range v {
+			v[k] = r.redactValue(k, nested)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactValue(key, item)
+		}
+		return v
+	case string:
+		for _, pattern := range r.patterns {
+			v = pattern.ReplaceAllString(v, redactedToken)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func hashValue(val interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v", val)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactorFieldName(t *testing.T) {
+	r := &Redactor{fieldNames: map[string]bool{"password": true}}
+
+	out, keep := r.Process([]byte(`{"password":"hunter2","log.level":"info"}`))
+	if !keep {
+		t.Fatal("Redactor should never drop a record")
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("output isn't valid JSON: %v", err)
+	}
+	if decoded["log.level"] != "info" {
+		t.Errorf("log.level = %v, want %q", decoded["log.level"], "info")
+	}
+	hashed, ok := decoded["password"].(string)
+	if !ok || !strings.HasPrefix(hashed, "sha256:") {
+		t.Errorf("password = %v, want a sha256: hash", decoded["password"])
+	}
+	if hashed == "hunter2" {
+		t.Error("password was not redacted")
+	}
+}
+
+func TestRedactorFieldNameCaseInsensitive(t *testing.T) {
+	r := &Redactor{fieldNames: map[string]bool{"password": true}}
+	out, _ := r.Process([]byte(`{"Password":"hunter2"}`))
+	var decoded map[string]interface{}
+	json.Unmarshal(out, &decoded)
+	if decoded["Password"] == "hunter2" {
+		t.Error("field name match should be case-insensitive")
+	}
+}
+
+func TestRedactorPattern(t *testing.T) {
+	r := &Redactor{patterns: []*regexp.Regexp{regexp.MustCompile(`\d{3}-\d{2}-\d{4}`)}}
+	out, _ := r.Process([]byte(`{"message":"ssn is 123-45-6789, ok"}`))
+	var decoded map[string]interface{}
+	json.Unmarshal(out, &decoded)
+	if decoded["message"] != "ssn is [REDACTED], ok" {
+		t.Errorf("message = %q, want %q", decoded["message"], "ssn is [REDACTED], ok")
+	}
+}
+
+func TestRedactorNested(t *testing.T) {
+	r := &Redactor{fieldNames: map[string]bool{"token": true}}
+	out, _ := r.Process([]byte(`{"fields":{"token":"secret"},"list":[{"token":"secret2"}]}`))
+	var decoded map[string]interface{}
+	json.Unmarshal(out, &decoded)
+	fields := decoded["fields"].(map[string]interface{})
+	if fields["token"] == "secret" {
+		t.Error("nested field name match was not redacted")
+	}
+	list := decoded["list"].([]interface{})
+	item := list[0].(map[string]interface{})
+	if item["token"] == "secret2" {
+		t.Error("field name match inside a list element was not redacted")
+	}
+}
+
+func TestRedactorNoopWhenUnconfigured(t *testing.T) {
+	r := &Redactor{}
+	envelope := []byte(`{"password":"hunter2"}`)
+	out, keep := r.Process(envelope)
+	if !keep {
+		t.Fatal("Redactor should never drop a record")
+	}
+	if string(out) != string(envelope) {
+		t.Errorf("unconfigured Redactor modified the envelope: got %q, want %q", out, envelope)
+	}
+}
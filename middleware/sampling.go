@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("sampling", &Sampling{})
+}
+
+// sampledLinesPerSecond is the assumed peak throughput a fraction of 1.0 is
+// scaled against; e.g. a fraction of 0.01 allows roughly 10 lines/sec
+// through, regardless of actual traffic.
+const sampledLinesPerSecond = 1000
+
+// Sampling rate-limits records at each configured log.level, using a token
+// bucket per level so bursts are smoothed rather than strictly capped
+// per-second. The limit is a rate, not a share of the actual traffic.
+type Sampling struct {
+	buckets map[string]*levelBucket
+}
+
+// levelBucket is a standard token bucket: it accumulates tokens at rate
+// per second up to capacity, and each record consumes one token.
+type levelBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func (b *levelBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Setup reads HABERDASHER_SAMPLING, a JSON object mapping log.level to a
+// fraction of sampledLinesPerSecond, e.g. {"error":1.0,"info":0.01} lets
+// through up to about 1000 error and 10 info records per second. Levels not
+// mentioned are kept unconditionally.
+func (s *Sampling) Setup() {
+	spec := os.Getenv("HABERDASHER_SAMPLING")
+	if spec == "" {
+		return
+	}
+	var fractions map[string]float64
+	if err := json.Unmarshal([]byte(spec), &fractions); err != nil {
+		log.Println("HABERDASHER_SAMPLING must be a JSON object of level->fraction:", err)
+		return
+	}
+	s.buckets = make(map[string]*levelBucket, len(fractions))
+	for level, fraction := range fractions {
+		rate := fraction * sampledLinesPerSecond
+		s.buckets[level] = &levelBucket{tokens: rate, capacity: rate, rate: rate, last: time.Now()}
+	}
+}
+
+// Process keeps envelope unmodified, reporting keep=false if its log.level
+// has a configured sampling rate and that rate's bucket is exhausted.
+func (s *Sampling) Process(envelope []byte) ([]byte, bool) {
+	if len(s.buckets) == 0 {
+		return envelope, true
+	}
+	bucket, configured := s.buckets[extractLevel(envelope)]
+	if !configured {
+		return envelope, true
+	}
+	return envelope, bucket.allow()
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLevelBucketAllow(t *testing.T) {
+	b := &levelBucket{tokens: 2, capacity: 2, rate: 2, last: time.Now()}
+
+	if !b.allow() {
+		t.Fatal("first token should be allowed")
+	}
+	if !b.allow() {
+		t.Fatal("second token should be allowed")
+	}
+	if b.allow() {
+		t.Fatal("bucket should be exhausted")
+	}
+
+	// Simulate one second passing without sleeping in the test.
+	b.last = b.last.Add(-time.Second)
+	if !b.allow() {
+		t.Fatal("bucket should have refilled after a second")
+	}
+}
+
+func TestLevelBucketCapsAtCapacity(t *testing.T) {
+	b := &levelBucket{tokens: 1, capacity: 1, rate: 1, last: time.Now()}
+
+	// Simulate a long idle period; tokens must not accumulate past capacity.
+	b.last = b.last.Add(-time.Hour)
+	if !b.allow() {
+		t.Fatal("expected a token to be available")
+	}
+	if b.allow() {
+		t.Fatal("bucket should not have banked more than capacity tokens")
+	}
+}
+
+func TestSamplingProcess(t *testing.T) {
+	s := &Sampling{buckets: map[string]*levelBucket{
+		"debug": {tokens: 1, capacity: 1, rate: 1, last: time.Now()},
+	}}
+
+	envelope := []byte(`{"log.level":"debug"}`)
+	out, keep := s.Process(envelope)
+	if !keep {
+		t.Fatal("first debug record should be kept")
+	}
+	if string(out) != string(envelope) {
+		t.Errorf("Process modified the envelope: got %q, want %q", out, envelope)
+	}
+	if _, keep := s.Process(envelope); keep {
+		t.Fatal("second debug record should be sampled out; bucket was exhausted")
+	}
+
+	// A level with no configured bucket is always kept.
+	errEnvelope := []byte(`{"log.level":"error"}`)
+	if _, keep := s.Process(errEnvelope); !keep {
+		t.Fatal("level with no configured sampling rate should always be kept")
+	}
+}
+
+func TestSamplingProcessNoBucketsConfigured(t *testing.T) {
+	s := &Sampling{}
+	envelope := []byte(`{"log.level":"debug"}`)
+	if _, keep := s.Process(envelope); !keep {
+		t.Fatal("Sampling with no configured buckets should keep everything")
+	}
+}
@@ -0,0 +1,55 @@
+package supervisor
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// RestartPolicy controls whether a Supervisor restarts the wrapped process
+// after it exits, modeled after the restart policies Kubernetes and
+// systemd offer.
+type RestartPolicy string
+
+const (
+	// RestartNo never restarts the process; its exit code is propagated
+	// immediately. This is the default.
+	RestartNo RestartPolicy = "no"
+	// RestartOnFailure restarts the process only if it exited with a
+	// non-zero status or was killed by a signal.
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartAlways restarts the process regardless of how it exited.
+	RestartAlways RestartPolicy = "always"
+)
+
+// defaultShutdownTimeout bounds how long the signal handler waits for the
+// pipeline to drain before exiting anyway.
+const defaultShutdownTimeout = 10 * time.Second
+
+// Config controls a Supervisor's process lifecycle behavior.
+type Config struct {
+	RestartPolicy   RestartPolicy
+	CaptureStdout   bool
+	ShutdownTimeout time.Duration
+}
+
+// ConfigFromEnv builds a Config from HABERDASHER_RESTART_POLICY,
+// HABERDASHER_CAPTURE_STDOUT and HABERDASHER_SHUTDOWN_TIMEOUT.
+func ConfigFromEnv() Config {
+	cfg := Config{RestartPolicy: RestartNo, ShutdownTimeout: defaultShutdownTimeout}
+	if v := os.Getenv("HABERDASHER_RESTART_POLICY"); v != "" {
+		switch RestartPolicy(v) {
+		case RestartNo, RestartOnFailure, RestartAlways:
+			cfg.RestartPolicy = RestartPolicy(v)
+		}
+	}
+	if captureStdout, err := strconv.ParseBool(os.Getenv("HABERDASHER_CAPTURE_STDOUT")); err == nil {
+		cfg.CaptureStdout = captureStdout
+	}
+	if v := os.Getenv("HABERDASHER_SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.ShutdownTimeout = d
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,242 @@
+// Package supervisor owns the wrapped subprocess's lifecycle: starting it,
+// piping its stderr (and optionally stdout) through a logging.Pipeline,
+// waiting for it to exit, propagating its exit code, and optionally
+// restarting it.
+package supervisor
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RedHatInsights/haberdasher/aggregator"
+	"github.com/RedHatInsights/haberdasher/logging"
+)
+
+// defaultScannerBufferSize is used when HABERDASHER_SCANNER_BUFFER_SIZE is
+// unset; it's larger than bufio.Scanner's own 64KiB default so a long JSON
+// log line isn't silently dropped.
+const defaultScannerBufferSize = 1 << 20 // 1MiB
+
+func scannerBufferSize() int {
+	if sizeStr, exists := os.LookupEnv("HABERDASHER_SCANNER_BUFFER_SIZE"); exists {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			return size
+		}
+	}
+	return defaultScannerBufferSize
+}
+
+// maxRestartBackoff caps the exponential backoff between restart attempts.
+const maxRestartBackoff = 30 * time.Second
+
+// terminatingSignals are the signals that should cancel any pending
+// restart, not just kill the current child incarnation - mirroring main's
+// terminating set, so a stop signal behaves the way it does under
+// systemd/k8s instead of being immediately undone by RestartPolicy.
+var terminatingSignals = map[syscall.Signal]bool{
+	syscall.SIGINT:  true,
+	syscall.SIGHUP:  true,
+	syscall.SIGTERM: true,
+}
+
+// Supervisor runs a subprocess, piping its stderr (and optionally stdout)
+// through a logging.Pipeline, and propagates its exit code to the caller of
+// Run. It restarts the subprocess according to its RestartPolicy.
+type Supervisor struct {
+	cfg      Config
+	bin      string
+	args     []string
+	pipeline *logging.Pipeline
+
+	mu       sync.Mutex
+	pid      int
+	stopping bool
+}
+
+// New returns a Supervisor for the given command, shipping its output
+// through pipeline.
+func New(cfg Config, bin string, args []string, pipeline *logging.Pipeline) *Supervisor {
+	return &Supervisor{cfg: cfg, bin: bin, args: args, pipeline: pipeline, pid: -1}
+}
+
+// Signal forwards sig to the running child, if one is currently running.
+// For a terminating signal, it also marks the Supervisor as stopping so
+// Run won't start another restart once the child exits.
+func (s *Supervisor) Signal(sig syscall.Signal) {
+	s.mu.Lock()
+	pid := s.pid
+	if terminatingSignals[sig] {
+		s.stopping = true
+	}
+	s.mu.Unlock()
+	if pid > 0 {
+		syscall.Kill(pid, sig)
+	}
+}
+
+// Run starts the child and blocks until it exits for good, i.e. until the
+// RestartPolicy no longer calls for another attempt or a terminating signal
+// was received, returning the exit code the caller should propagate.
+func (s *Supervisor) Run() int {
+	backoff := time.Second
+	for {
+		exitCode, signaled := s.runOnce()
+
+		s.mu.Lock()
+		stopping := s.stopping
+		s.mu.Unlock()
+		if stopping {
+			return exitCode
+		}
+
+		restart := false
+		switch s.cfg.RestartPolicy {
+		case RestartAlways:
+			restart = true
+		case RestartOnFailure:
+			restart = signaled || exitCode != 0
+		}
+		if !restart {
+			return exitCode
+		}
+		log.Printf("Subprocess exited (code %d), restarting per policy %q in %s", exitCode, s.cfg.RestartPolicy, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// runOnce starts the child once, waits for it to exit, and reports its
+// exit code along with whether it was killed by a signal.
+func (s *Supervisor) runOnce() (exitCode int, signaled bool) {
+	cmd := exec.Command(s.bin, s.args...)
+
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var stdoutPipe io.ReadCloser
+	if s.cfg.CaptureStdout {
+		stdoutPipe, err = cmd.StdoutPipe()
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		cmd.Stdout = os.Stdout
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Fatal(err)
+	}
+	s.mu.Lock()
+	s.pid = cmd.Process.Pid
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		consume(stderrPipe, s.pipeline)
+	}()
+	if s.cfg.CaptureStdout {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consume(stdoutPipe, s.pipeline)
+		}()
+	}
+	wg.Wait()
+
+	err = cmd.Wait()
+	s.mu.Lock()
+	s.pid = -1
+	s.mu.Unlock()
+
+	if err == nil {
+		return 0, false
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		log.Println("Error waiting for subprocess:", err)
+		return 1, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return exitErr.ExitCode(), false
+	}
+	if status.Signaled() {
+		return exitCodeFromSignal(status.Signal()), true
+	}
+	return status.ExitStatus(), false
+}
+
+// exitCodeFromSignal maps a signal that killed the child to the exit code
+// we propagate, following the shell convention that a process killed by
+// signal N exits 128+N.
+func exitCodeFromSignal(sig syscall.Signal) int {
+	return 128 + int(sig)
+}
+
+// consume reads newline-delimited records from r - the child's stderr or,
+// when captured, its stdout - stitching multi-line records via the
+// aggregator package before submitting each to pipeline.
+func consume(r io.Reader, pipeline *logging.Pipeline) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), scannerBufferSize())
+
+	multilineCfg, multilineEnabled, err := aggregator.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("invalid HABERDASHER_MULTILINE_PATTERN:", err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	if !multilineEnabled {
+		for line := range lines {
+			pipeline.Submit(line, false)
+		}
+		return
+	}
+
+	agg := aggregator.New(multilineCfg)
+	timer := time.NewTimer(multilineCfg.Timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, open := <-lines:
+			if !open {
+				if rec, ok := agg.Flush(); ok {
+					pipeline.Submit(rec.Text, rec.IsTrace)
+				}
+				return
+			}
+			if rec, ok := agg.Feed(line); ok {
+				pipeline.Submit(rec.Text, rec.IsTrace)
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(multilineCfg.Timeout)
+		case <-timer.C:
+			if rec, ok := agg.Flush(); ok {
+				pipeline.Submit(rec.Text, rec.IsTrace)
+			}
+			timer.Reset(multilineCfg.Timeout)
+		}
+	}
+}
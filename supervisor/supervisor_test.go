@@ -0,0 +1,36 @@
+package supervisor
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestSignalMarksStoppingOnlyForTerminatingSignals(t *testing.T) {
+	s := &Supervisor{pid: -1}
+
+	s.Signal(syscall.SIGUSR1)
+	if s.stopping {
+		t.Fatal("a non-terminating signal should not mark the Supervisor as stopping")
+	}
+
+	s.Signal(syscall.SIGTERM)
+	if !s.stopping {
+		t.Fatal("a terminating signal should mark the Supervisor as stopping")
+	}
+}
+
+func TestExitCodeFromSignal(t *testing.T) {
+	cases := []struct {
+		sig  syscall.Signal
+		want int
+	}{
+		{syscall.SIGTERM, 128 + 15},
+		{syscall.SIGINT, 128 + 2},
+		{syscall.SIGKILL, 128 + 9},
+	}
+	for _, tc := range cases {
+		if got := exitCodeFromSignal(tc.sig); got != tc.want {
+			t.Errorf("exitCodeFromSignal(%v) = %d, want %d", tc.sig, got, tc.want)
+		}
+	}
+}